@@ -2,17 +2,25 @@ package servecmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 
 	"github.com/openshift-online/maestro/cmd/maestro/environments"
 	"github.com/openshift-online/maestro/cmd/maestro/server"
+	"github.com/openshift-online/maestro/pkg/dispatcher"
+	"github.com/openshift-online/maestro/pkg/lifecycle"
 )
 
+// drainDeadline bounds how long shutdown waits for all registered servers and workers to
+// finish before giving up, once a SIGTERM/SIGINT is received.
+const drainDeadline = 30 * time.Second
+
 func NewServeCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "server",
@@ -24,6 +32,10 @@ func NewServeCommand() *cobra.Command {
 	if err != nil {
 		glog.Fatalf("Unable to add environment flags to serve command: %s", err.Error())
 	}
+	cmd.PersistentFlags().String("dispatcher-backend", dispatcher.BackendHash,
+		fmt.Sprintf("Dispatcher backend to use, one of %q or %q", dispatcher.BackendHash, dispatcher.BackendRaft))
+	cmd.PersistentFlags().Bool("enable-failpoints", false,
+		"Mount the failpoint toggle endpoint on the metrics server, for chaos testing. Not for production use.")
 
 	return cmd
 }
@@ -34,40 +46,59 @@ func runServer(cmd *cobra.Command, args []string) {
 		glog.Fatalf("Unable to initialize environment: %s", err.Error())
 	}
 
+	dispatcherBackend, err := cmd.Flags().GetString("dispatcher-backend")
+	if err != nil {
+		glog.Fatalf("Unable to read dispatcher-backend flag: %s", err.Error())
+	}
+
+	enableFailpoints, err := cmd.Flags().GetBool("enable-failpoints")
+	if err != nil {
+		glog.Fatalf("Unable to read enable-failpoints flag: %s", err.Error())
+	}
+
 	// Create the servers
 	apiserver := server.NewAPIServer()
-	metricsServer := server.NewMetricsServer()
+	metricsServer := server.NewMetricsServer(enableFailpoints)
 	healthcheckServer := server.NewHealthCheckServer()
 	pulseServer := server.NewPulseServer()
-	controllersServer := server.NewControllersServer()
+	controllersServer := server.NewControllersServer(dispatcherBackend)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// stopper tracks every server/worker below and performs ordered, bounded,
+	// error-reporting shutdown of all of them from a single Stop(ctx) call.
+	stopper := lifecycle.NewStopper(drainDeadline)
+	stopper.AddCloser("api-server", apiserver)
+	stopper.AddCloser("metrics-server", metricsServer)
+	stopper.AddCloser("healthcheck-server", healthcheckServer)
+	controllersServer.WithStopper(stopper)
+	healthcheckServer.RegisterStopper(stopper)
 
-	stopCh := make(chan os.Signal, 1)
-	signal.Notify(stopCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		defer cancel()
-		<-stopCh
-		// Received SIGTERM or SIGINT signal, shutting down servers gracefully.
-		if err := apiserver.Stop(); err != nil {
-			glog.Errorf("Failed to stop api server, %v", err)
-		}
-
-		if err := metricsServer.Stop(); err != nil {
-			glog.Errorf("Failed to stop metrics server, %v", err)
-		}
-
-		if err := healthcheckServer.Stop(); err != nil {
-			glog.Errorf("Failed to stop healthcheck server, %v", err)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopper.RunWorker(ctx, "quiesce-watcher", func(ctx context.Context) {
+		// cancel ctx as soon as the stopper quiesces, so the ctx-aware servers below
+		// wind down alongside the Stop()-based ones.
+		select {
+		case <-stopper.ShouldQuiesce():
+			cancel()
+		case <-ctx.Done():
 		}
-	}()
+	})
 
 	// Run the servers
-	go apiserver.Start()
-	go metricsServer.Start()
-	go healthcheckServer.Start()
-	go pulseServer.Start(ctx)
-	go controllersServer.Start(ctx)
+	stopper.RunWorker(ctx, "api-server", func(ctx context.Context) { apiserver.Start() })
+	stopper.RunWorker(ctx, "metrics-server", func(ctx context.Context) { metricsServer.Start() })
+	stopper.RunWorker(ctx, "healthcheck-server", func(ctx context.Context) { healthcheckServer.Start() })
+	stopper.RunWorker(ctx, "pulse-server", pulseServer.Start)
+	stopper.RunWorker(ctx, "controllers-server", controllersServer.Start)
 
-	<-ctx.Done()
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, syscall.SIGINT, syscall.SIGTERM)
+	<-stopCh
+
+	// Received SIGTERM or SIGINT signal, shut down all registered servers and workers,
+	// bounded by drainDeadline.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), drainDeadline)
+	defer shutdownCancel()
+	if err := stopper.Stop(shutdownCtx); err != nil {
+		glog.Errorf("Error during shutdown: %s", err.Error())
+	}
 }
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang/glog"
+)
+
+// APIServer serves maestro's REST API.
+type APIServer struct {
+	httpServer *http.Server
+}
+
+// NewAPIServer creates the API server.
+func NewAPIServer() *APIServer {
+	return &APIServer{
+		httpServer: &http.Server{Addr: ":8000", Handler: http.NewServeMux()},
+	}
+}
+
+// Start serves the API until Stop is called.
+func (s *APIServer) Start() {
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		glog.Errorf("API server error: %s", err.Error())
+	}
+}
+
+// Stop implements lifecycle.Closer.
+func (s *APIServer) Stop() error {
+	return s.httpServer.Shutdown(context.Background())
+}
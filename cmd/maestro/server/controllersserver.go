@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/openshift-online/maestro/pkg/client/cloudevents"
+	"github.com/openshift-online/maestro/pkg/dao"
+	"github.com/openshift-online/maestro/pkg/dispatcher"
+	"github.com/openshift-online/maestro/pkg/lifecycle"
+)
+
+// resyncSweepInterval is how often the elected leader re-requests a full resource status
+// resync for every known consumer, as a cluster-wide safety net. The sweep only runs with
+// the raft dispatcher backend (see Start).
+const resyncSweepInterval = 10 * time.Minute
+
+// ControllersServer runs maestro's background controllers, including the Dispatcher that
+// decides which maestro instance owns each consumer.
+type ControllersServer struct {
+	dispatcher   dispatcher.Dispatcher
+	consumerDao  dao.ConsumerDao
+	sourceClient cloudevents.SourceClient
+	etcdClient   *clientv3.Client
+}
+
+// NewControllersServer builds the controllers server, selecting the Dispatcher
+// implementation named by dispatcherBackend (dispatcher.BackendHash or
+// dispatcher.BackendRaft; see --dispatcher-backend). When MAESTRO_DATABASE_URL is set, the
+// server_instances/consumers LISTEN/NOTIFY watchers are wired in so the dispatcher can
+// react to membership changes as they happen, instead of only on its reconciliation loop.
+func NewControllersServer(dispatcherBackend string) *ControllersServer {
+	instanceID := os.Getenv("MAESTRO_INSTANCE_ID")
+	databaseURL := os.Getenv("MAESTRO_DATABASE_URL")
+
+	instanceDao := dao.NewInstanceDao(databaseURL)
+	consumerDao := dao.NewConsumerDao(databaseURL)
+	sourceClient := cloudevents.NewSourceClient()
+
+	var membershipWatcher dispatcher.MembershipWatcher
+	var consumerWatcher dispatcher.ConsumerWatcher
+	if databaseURL != "" {
+		membershipWatcher = dispatcher.NewPostgresMembershipWatcher(databaseURL)
+		consumerWatcher = dispatcher.NewPostgresConsumerWatcher(databaseURL)
+	}
+
+	var etcdClient *clientv3.Client
+	if dispatcherBackend == dispatcher.BackendRaft {
+		endpointsEnv := os.Getenv("MAESTRO_ETCD_ENDPOINTS")
+		if endpointsEnv == "" {
+			glog.Fatalf("MAESTRO_ETCD_ENDPOINTS must be set when --dispatcher-backend=%s", dispatcher.BackendRaft)
+		}
+		endpoints := strings.Split(endpointsEnv, ",")
+		var err error
+		etcdClient, err = clientv3.New(clientv3.Config{Endpoints: endpoints})
+		if err != nil {
+			glog.Fatalf("Unable to create etcd client for raft dispatcher: %s", err.Error())
+		}
+	}
+
+	d, err := dispatcher.NewDispatcher(dispatcherBackend, instanceID, instanceDao, consumerDao, sourceClient,
+		membershipWatcher, consumerWatcher, etcdClient, dispatcher.DefaultConfig())
+	if err != nil {
+		glog.Fatalf("Unable to create dispatcher: %s", err.Error())
+	}
+
+	return &ControllersServer{dispatcher: d, consumerDao: consumerDao, sourceClient: sourceClient, etcdClient: etcdClient}
+}
+
+// WithStopper registers the dispatcher's background workers with stopper, so a single
+// stopper.Stop(ctx) drains them alongside every other server. Must be called before Start.
+// Only a HashDispatcher's workers are registered; with the raft backend this does nothing.
+func (s *ControllersServer) WithStopper(stopper *lifecycle.Stopper) *ControllersServer {
+	if hd, ok := s.dispatcher.(*dispatcher.HashDispatcher); ok {
+		hd.WithStopper(stopper)
+	}
+	return s
+}
+
+// Dispatch returns true if the given consumer is owned by the current maestro instance.
+func (s *ControllersServer) Dispatch(consumerID string) bool {
+	return s.dispatcher.Dispatch(consumerID)
+}
+
+// Start runs the dispatcher until ctx is canceled. When the raft backend is selected, the
+// dispatcher's etcd cluster doubles as the leader-election backend for the resync-sweep
+// controller below, via dispatcher.RunAsSingleton.
+func (s *ControllersServer) Start(ctx context.Context) {
+	if s.etcdClient != nil {
+		go func() {
+			if err := dispatcher.RunAsSingleton(ctx, s.etcdClient, "resync-sweep", s.runResyncSweep); err != nil {
+				glog.Errorf("resync-sweep controller exited: %s", err.Error())
+			}
+		}()
+	}
+
+	s.dispatcher.Start(ctx)
+}
+
+// runResyncSweep is the first controller that needs single-writer semantics; only the
+// elected leader runs it, so later additions can follow the same RunAsSingleton pattern. It
+// periodically re-requests a full resource status resync for every known consumer, catching
+// up on any resync an owning instance may have missed (e.g. a consumer whose owning
+// instance crashed before resyncing it).
+func (s *ControllersServer) runResyncSweep(ctx context.Context) {
+	ticker := time.NewTicker(resyncSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			consumers, err := s.consumerDao.All(ctx)
+			if err != nil {
+				glog.Errorf("resync-sweep: unable to list consumers: %s", err.Error())
+				continue
+			}
+			consumerIDs := make([]string, len(consumers))
+			for i, consumer := range consumers {
+				consumerIDs[i] = consumer.ID
+			}
+			if err := s.sourceClient.Resync(ctx, consumerIDs); err != nil {
+				glog.Errorf("resync-sweep: unable to resync %d consumer(s): %s", len(consumerIDs), err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+
+	"github.com/openshift-online/maestro/pkg/lifecycle"
+)
+
+// HealthCheckServer serves liveness/readiness probes, plus shutdown progress once
+// RegisterStopper has been called.
+type HealthCheckServer struct {
+	httpServer *http.Server
+	stopper    *lifecycle.Stopper
+}
+
+// NewHealthCheckServer creates the health check server.
+func NewHealthCheckServer() *HealthCheckServer {
+	s := &HealthCheckServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/healthz/shutdown", s.serveShutdownProgress)
+	s.httpServer = &http.Server{Addr: ":8083", Handler: mux}
+	return s
+}
+
+// RegisterStopper wires stopper into the health server so /healthz/shutdown can report the
+// process's shutdown progress.
+func (s *HealthCheckServer) RegisterStopper(stopper *lifecycle.Stopper) {
+	s.stopper = stopper
+}
+
+func (s *HealthCheckServer) serveShutdownProgress(w http.ResponseWriter, r *http.Request) {
+	if s.stopper == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	progress := struct {
+		TasksRemaining int  `json:"tasksRemaining"`
+		Stopped        bool `json:"stopped"`
+	}{
+		TasksRemaining: s.stopper.NumTasks(),
+	}
+	select {
+	case <-s.stopper.IsStopped():
+		progress.Stopped = true
+	default:
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(progress); err != nil {
+		glog.Errorf("Unable to encode shutdown progress: %s", err.Error())
+	}
+}
+
+// Start serves health checks until Stop is called.
+func (s *HealthCheckServer) Start() {
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		glog.Errorf("Healthcheck server error: %s", err.Error())
+	}
+}
+
+// Stop implements lifecycle.Closer.
+func (s *HealthCheckServer) Stop() error {
+	return s.httpServer.Shutdown(context.Background())
+}
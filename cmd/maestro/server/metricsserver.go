@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/openshift-online/maestro/pkg/failpoint"
+)
+
+// MetricsServer serves Prometheus metrics and, when enabled, the failpoint toggle endpoint.
+type MetricsServer struct {
+	httpServer *http.Server
+}
+
+// NewMetricsServer creates the metrics server. When enableFailpoints is true, it also
+// mounts failpoint.Handler() at /debug/failpoints, for chaos testing; never enable this in
+// production.
+func NewMetricsServer(enableFailpoints bool) *MetricsServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if enableFailpoints {
+		glog.Warning("Failpoint endpoint enabled on the metrics server; do not use in production")
+		mux.Handle("/debug/failpoints", failpoint.Handler())
+	}
+
+	return &MetricsServer{
+		httpServer: &http.Server{Addr: ":8080", Handler: mux},
+	}
+}
+
+// Start serves metrics until Stop is called.
+func (s *MetricsServer) Start() {
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		glog.Errorf("Metrics server error: %s", err.Error())
+	}
+}
+
+// Stop implements lifecycle.Closer.
+func (s *MetricsServer) Stop() error {
+	return s.httpServer.Shutdown(context.Background())
+}
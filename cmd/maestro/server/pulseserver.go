@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openshift-online/maestro/pkg/api"
+	"github.com/openshift-online/maestro/pkg/dao"
+	"github.com/openshift-online/maestro/pkg/failpoint"
+	"github.com/openshift-online/maestro/pkg/logger"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// defaultInstanceWeight is used when MAESTRO_INSTANCE_WEIGHT is unset or unparsable, matching
+// HashDispatcher's own treatment of an unset Weight.
+const defaultInstanceWeight = int32(1)
+
+// PulseServer periodically refreshes this instance's heartbeat in the server_instances
+// table, so other instances can detect it going away via HashDispatcher's reconciliation
+// loop, which stops seeing an instance once its heartbeat goes stale (see InstanceDao.All).
+type PulseServer struct {
+	instanceID  string
+	instanceDao dao.InstanceDao
+}
+
+// NewPulseServer creates the pulse server for this process's instance, reading its identity
+// and database connection the same way NewControllersServer does.
+func NewPulseServer() *PulseServer {
+	return &PulseServer{
+		instanceID:  os.Getenv("MAESTRO_INSTANCE_ID"),
+		instanceDao: dao.NewInstanceDao(os.Getenv("MAESTRO_DATABASE_URL")),
+	}
+}
+
+// Start beats every heartbeatInterval until ctx is canceled.
+func (s *PulseServer) Start(ctx context.Context) {
+	log := logger.NewOCMLogger(ctx)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.beat(ctx); err != nil {
+				log.Error(fmt.Sprintf("Unable to update heartbeat for instance %s: %s", s.instanceID, err.Error()))
+			}
+		}
+	}
+}
+
+// beat is a separate method so tests can exercise a single heartbeat without waiting on
+// the ticker.
+func (s *PulseServer) beat(ctx context.Context) error {
+	if failpoint.Eval(failpoint.DropInstanceHeartbeat) {
+		// simulate a missed heartbeat/DB blip
+		return nil
+	}
+	return s.instanceDao.Upsert(ctx, &api.ServerInstance{
+		Meta:     api.Meta{ID: s.instanceID},
+		Weight:   instanceWeight(),
+		Draining: instanceDraining(),
+	})
+}
+
+// instanceWeight reads this instance's relative capacity from MAESTRO_INSTANCE_WEIGHT, as
+// populated by a Downward API env var sourced from the pod's resource requests. An unset or
+// unparsable value falls back to defaultInstanceWeight.
+func instanceWeight() int32 {
+	weight, err := strconv.ParseInt(os.Getenv("MAESTRO_INSTANCE_WEIGHT"), 10, 32)
+	if err != nil || weight <= 0 {
+		return defaultInstanceWeight
+	}
+	return int32(weight)
+}
+
+// instanceDraining reports whether this instance is cordoned for a planned drain. Unlike
+// Weight, draining must be able to start and end while the process keeps running, so it is
+// signaled by the presence of a marker file at MAESTRO_DRAIN_FILE (e.g. written by a preStop
+// hook or an operator draining the instance ahead of a rolling restart) rather than a static
+// environment variable.
+func instanceDraining() bool {
+	path := os.Getenv("MAESTRO_DRAIN_FILE")
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
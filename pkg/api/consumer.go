@@ -0,0 +1,8 @@
+package api
+
+// Consumer represents a maestro consumer (typically a managed cluster agent) whose
+// resource status updates are owned by exactly one maestro instance at a time, as decided
+// by a Dispatcher.
+type Consumer struct {
+	Meta
+}
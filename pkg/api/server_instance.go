@@ -0,0 +1,29 @@
+package api
+
+// Meta holds identity fields common to persisted maestro resources.
+type Meta struct {
+	ID string
+}
+
+// ServerInstance represents a running maestro server instance, as tracked in the
+// server_instances table and used by dispatchers to build their membership view.
+type ServerInstance struct {
+	Meta
+
+	// Weight is this instance's relative capacity, e.g. derived from its pod's CPU/mem
+	// requests by the pulse server. Dispatchers give heavier instances proportionally
+	// more replicas on the consistent hash ring. An unset (zero) Weight is treated as 1.
+	Weight int32 `json:"weight,omitempty"`
+
+	// Draining is true while the instance is cordoned for a planned drain, as refreshed
+	// by the pulse server from the presence of the MAESTRO_DRAIN_FILE marker file; pod
+	// annotations are not read. A draining instance is removed
+	// from the hash ring for new placement decisions, but keeps processing the
+	// consumers it already owns until a new owner takes over.
+	Draining bool `json:"draining,omitempty"`
+}
+
+// String implements consistent.Member (github.com/buraksezer/consistent).
+func (s *ServerInstance) String() string {
+	return s.ID
+}
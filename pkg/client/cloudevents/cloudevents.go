@@ -0,0 +1,24 @@
+// Package cloudevents provides maestro's cloudevents source-side client, used by
+// dispatchers to request a full resource status resync for a set of consumers.
+package cloudevents
+
+import "context"
+
+// SourceClient requests resource status resyncs over cloudevents.
+type SourceClient interface {
+	// Resync asks every consumer in consumerIDs to resend its current resource status.
+	Resync(ctx context.Context, consumerIDs []string) error
+}
+
+// noopSourceClient is a SourceClient that does nothing; it is used until a real
+// cloudevents source is wired in.
+type noopSourceClient struct{}
+
+// NewSourceClient returns the default SourceClient.
+func NewSourceClient() SourceClient {
+	return noopSourceClient{}
+}
+
+func (noopSourceClient) Resync(ctx context.Context, consumerIDs []string) error {
+	return nil
+}
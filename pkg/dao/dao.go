@@ -0,0 +1,34 @@
+// Package dao provides data-access interfaces for the maestro resources that dispatchers
+// need a view of, so that pkg/dispatcher does not depend directly on a storage backend.
+package dao
+
+import (
+	"context"
+
+	"github.com/openshift-online/maestro/pkg/api"
+)
+
+// InstanceDao provides access to the persisted set of maestro server instances.
+type InstanceDao interface {
+	// All returns every known maestro server instance, including draining ones, excluding
+	// any instance whose heartbeat has gone stale (see postgresInstanceDao's
+	// instanceStaleAfter) so a crashed instance's row doesn't stay "active" forever.
+	All(ctx context.Context) ([]*api.ServerInstance, error)
+	// Upsert persists instance's current Weight/Draining and refreshes its last-seen time
+	// in one call, so other instances can tell it is still alive and place it on the ring
+	// with up-to-date capacity/drain state. Called by the pulse server every heartbeat.
+	Upsert(ctx context.Context, instance *api.ServerInstance) error
+}
+
+// ConsumerDao provides access to the persisted set of consumers, plus the lightweight
+// ownership bookkeeping HashDispatcher uses to confirm a drain handoff before a draining
+// instance stops serving a consumer it still owns.
+type ConsumerDao interface {
+	// All returns every known consumer.
+	All(ctx context.Context) ([]*api.Consumer, error)
+	// ClaimOwner records that instanceID is now processing consumerID, so other
+	// instances can confirm a handoff before giving it up themselves.
+	ClaimOwner(ctx context.Context, consumerID, instanceID string) error
+	// Owner returns the instance ID that last claimed consumerID, or "" if none has.
+	Owner(ctx context.Context, consumerID string) (string, error)
+}
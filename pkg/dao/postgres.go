@@ -0,0 +1,159 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	// registers the "postgres" driver used by sql.Open below.
+	_ "github.com/lib/pq"
+
+	"github.com/openshift-online/maestro/pkg/api"
+)
+
+// instanceStaleAfter bounds how long an instance can go without a heartbeat (see Upsert)
+// before All stops returning it, so a crashed instance whose process never got to remove its
+// own row doesn't stay "active" forever. It is several multiples of the pulse server's
+// heartbeat interval, so a couple of missed beats don't drop a live instance from All.
+const instanceStaleAfter = 2 * time.Minute
+
+// postgresInstanceDao is a database/sql-backed InstanceDao over the server_instances table.
+type postgresInstanceDao struct {
+	db *sql.DB
+}
+
+// NewInstanceDao opens a connection pool to connStr and returns an InstanceDao backed by
+// the server_instances table. An empty connStr returns a DAO whose All always returns no
+// instances, so callers can run with the database disabled (e.g. local dev).
+func NewInstanceDao(connStr string) InstanceDao {
+	if connStr == "" {
+		return &postgresInstanceDao{}
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return &postgresInstanceDao{}
+	}
+	return &postgresInstanceDao{db: db}
+}
+
+func (d *postgresInstanceDao) All(ctx context.Context) ([]*api.ServerInstance, error) {
+	if d.db == nil {
+		return nil, nil
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, weight, draining FROM server_instances
+		WHERE last_seen > now() - make_interval(secs => $1)`,
+		instanceStaleAfter.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("unable to query server_instances: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var instances []*api.ServerInstance
+	for rows.Next() {
+		instance := &api.ServerInstance{}
+		if err := rows.Scan(&instance.ID, &instance.Weight, &instance.Draining); err != nil {
+			return nil, fmt.Errorf("unable to scan server_instances row: %s", err.Error())
+		}
+		instances = append(instances, instance)
+	}
+	return instances, rows.Err()
+}
+
+// Upsert persists instance's Weight/Draining and refreshes its last_seen column in one
+// statement, creating the row if it doesn't exist yet (e.g. the first heartbeat after the
+// instance comes up).
+func (d *postgresInstanceDao) Upsert(ctx context.Context, instance *api.ServerInstance) error {
+	if d.db == nil {
+		return nil
+	}
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO server_instances (id, weight, draining, last_seen)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (id) DO UPDATE SET weight = $2, draining = $3, last_seen = now()`,
+		instance.ID, instance.Weight, instance.Draining)
+	if err != nil {
+		return fmt.Errorf("unable to update heartbeat for instance %s: %s", instance.ID, err.Error())
+	}
+	return nil
+}
+
+// postgresConsumerDao is a database/sql-backed ConsumerDao over the consumers table, plus
+// a consumer_owners table used for drain-handoff confirmation (see ClaimOwner/Owner).
+type postgresConsumerDao struct {
+	db *sql.DB
+}
+
+// NewConsumerDao opens a connection pool to connStr and returns a ConsumerDao backed by the
+// consumers table. An empty connStr returns a DAO whose All always returns no consumers.
+func NewConsumerDao(connStr string) ConsumerDao {
+	if connStr == "" {
+		return &postgresConsumerDao{}
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return &postgresConsumerDao{}
+	}
+	return &postgresConsumerDao{db: db}
+}
+
+func (d *postgresConsumerDao) All(ctx context.Context) ([]*api.Consumer, error) {
+	if d.db == nil {
+		return nil, nil
+	}
+
+	rows, err := d.db.QueryContext(ctx, "SELECT id FROM consumers")
+	if err != nil {
+		return nil, fmt.Errorf("unable to query consumers: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var consumers []*api.Consumer
+	for rows.Next() {
+		consumer := &api.Consumer{}
+		if err := rows.Scan(&consumer.ID); err != nil {
+			return nil, fmt.Errorf("unable to scan consumers row: %s", err.Error())
+		}
+		consumers = append(consumers, consumer)
+	}
+	return consumers, rows.Err()
+}
+
+// ClaimOwner upserts consumer_owners so Owner can confirm instanceID has taken ownership of
+// consumerID.
+func (d *postgresConsumerDao) ClaimOwner(ctx context.Context, consumerID, instanceID string) error {
+	if d.db == nil {
+		return nil
+	}
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO consumer_owners (consumer_id, instance_id, claimed_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (consumer_id) DO UPDATE SET instance_id = $2, claimed_at = now()`,
+		consumerID, instanceID)
+	if err != nil {
+		return fmt.Errorf("unable to claim consumer %s for instance %s: %s", consumerID, instanceID, err.Error())
+	}
+	return nil
+}
+
+// Owner returns the instance ID consumer_owners last recorded for consumerID, or "" if
+// none has claimed it yet.
+func (d *postgresConsumerDao) Owner(ctx context.Context, consumerID string) (string, error) {
+	if d.db == nil {
+		return "", nil
+	}
+
+	var instanceID string
+	err := d.db.QueryRowContext(ctx, "SELECT instance_id FROM consumer_owners WHERE consumer_id = $1", consumerID).Scan(&instanceID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read owner of consumer %s: %s", consumerID, err.Error())
+	}
+	return instanceID, nil
+}
@@ -0,0 +1,219 @@
+//go:build failpoints
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift-online/maestro/pkg/api"
+	"github.com/openshift-online/maestro/pkg/failpoint"
+	"github.com/openshift-online/maestro/pkg/lifecycle"
+)
+
+// fakeInstanceDao is an in-memory InstanceDao that can be made to fail All(), to
+// simulate a DB blip.
+type fakeInstanceDao struct {
+	mu        sync.Mutex
+	instances []*api.ServerInstance
+	failNext  int
+}
+
+func (f *fakeInstanceDao) All(ctx context.Context) ([]*api.ServerInstance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failNext > 0 {
+		f.failNext--
+		return nil, fmt.Errorf("simulated database blip")
+	}
+	out := make([]*api.ServerInstance, len(f.instances))
+	copy(out, f.instances)
+	return out, nil
+}
+
+func (f *fakeInstanceDao) Upsert(ctx context.Context, instance *api.ServerInstance) error { return nil }
+
+func (f *fakeInstanceDao) set(instances []*api.ServerInstance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.instances = instances
+}
+
+// fakeConsumerDao is an in-memory ConsumerDao with the ownership bookkeeping HashDispatcher
+// uses to confirm a drain handoff, and a failNext hook so All() can simulate a DB blip.
+type fakeConsumerDao struct {
+	mu        sync.Mutex
+	consumers []*api.Consumer
+	owners    map[string]string
+	failNext  int
+}
+
+func newFakeConsumerDao(consumers []*api.Consumer) *fakeConsumerDao {
+	return &fakeConsumerDao{consumers: consumers, owners: map[string]string{}}
+}
+
+func (f *fakeConsumerDao) All(ctx context.Context) ([]*api.Consumer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failNext > 0 {
+		f.failNext--
+		return nil, fmt.Errorf("simulated database blip")
+	}
+	out := make([]*api.Consumer, len(f.consumers))
+	copy(out, f.consumers)
+	return out, nil
+}
+
+func (f *fakeConsumerDao) ClaimOwner(ctx context.Context, consumerID, instanceID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.owners[consumerID] = instanceID
+	return nil
+}
+
+func (f *fakeConsumerDao) Owner(ctx context.Context, consumerID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.owners[consumerID], nil
+}
+
+// fakeSourceClient is a no-op cloudevents.SourceClient.
+type fakeSourceClient struct{}
+
+func (fakeSourceClient) Resync(ctx context.Context, consumerIDs []string) error { return nil }
+
+func consumerIDs(n int) []*api.Consumer {
+	consumers := make([]*api.Consumer, n)
+	for i := range consumers {
+		consumers[i] = &api.Consumer{Meta: api.Meta{ID: fmt.Sprintf("consumer-%d", i)}}
+	}
+	return consumers
+}
+
+// TestEventualConsistencyAfterInstanceDeath simulates an instance dying mid-rebalance: two
+// HashDispatchers share a membership/consumer view; once one instance disappears from the
+// shared InstanceDao, the survivor's reconcile loop must pick up every consumer the dead
+// instance used to own.
+func TestEventualConsistencyAfterInstanceDeath(t *testing.T) {
+	instanceDao := &fakeInstanceDao{instances: []*api.ServerInstance{
+		{Meta: api.Meta{ID: "instance-a"}},
+		{Meta: api.Meta{ID: "instance-b"}},
+	}}
+	consumerDao := newFakeConsumerDao(consumerIDs(20))
+
+	a := NewHashDispatcher("instance-a", instanceDao, consumerDao, fakeSourceClient{}, nil, nil, DefaultConfig())
+	b := NewHashDispatcher("instance-b", instanceDao, consumerDao, fakeSourceClient{}, nil, nil, DefaultConfig())
+
+	a.check()
+	b.check()
+
+	total := a.consumerSet.Cardinality() + b.consumerSet.Cardinality()
+	if total != 20 {
+		t.Fatalf("expected all 20 consumers owned exactly once across both instances, got %d", total)
+	}
+
+	// instance-b dies; only instance-a's reconcile loop keeps running.
+	instanceDao.set([]*api.ServerInstance{{Meta: api.Meta{ID: "instance-a"}}})
+	a.check()
+
+	if a.consumerSet.Cardinality() != 20 {
+		t.Fatalf("expected instance-a to own all 20 consumers after instance-b died, got %d", a.consumerSet.Cardinality())
+	}
+}
+
+// TestEventualConsistencyAfterDatabaseBlip simulates a transient DB error from
+// instanceDao.All: the reconcile pass that hits it must not corrupt existing state, and a
+// later, successful pass must still converge.
+func TestEventualConsistencyAfterDatabaseBlip(t *testing.T) {
+	instanceDao := &fakeInstanceDao{instances: []*api.ServerInstance{{Meta: api.Meta{ID: "instance-a"}}}}
+	consumerDao := newFakeConsumerDao(consumerIDs(5))
+
+	d := NewHashDispatcher("instance-a", instanceDao, consumerDao, fakeSourceClient{}, nil, nil, DefaultConfig())
+	d.check()
+	if d.consumerSet.Cardinality() != 5 {
+		t.Fatalf("expected 5 consumers owned before the blip, got %d", d.consumerSet.Cardinality())
+	}
+
+	instanceDao.failNext = 1
+	d.check() // should log and return early, leaving consumerSet untouched
+	if d.consumerSet.Cardinality() != 5 {
+		t.Fatalf("expected consumer set to survive a failed reconcile pass, got %d", d.consumerSet.Cardinality())
+	}
+
+	d.check() // blip resolved, should still be consistent
+	if d.consumerSet.Cardinality() != 5 {
+		t.Fatalf("expected 5 consumers owned after the blip cleared, got %d", d.consumerSet.Cardinality())
+	}
+}
+
+// TestEventualConsistencyAfterConsumerDaoBlip simulates a transient DB error from
+// consumerDao.All: the reconcile pass that hits it must not corrupt existing state, and a
+// later, successful pass must still converge.
+func TestEventualConsistencyAfterConsumerDaoBlip(t *testing.T) {
+	instanceDao := &fakeInstanceDao{instances: []*api.ServerInstance{{Meta: api.Meta{ID: "instance-a"}}}}
+	consumerDao := newFakeConsumerDao(consumerIDs(5))
+
+	d := NewHashDispatcher("instance-a", instanceDao, consumerDao, fakeSourceClient{}, nil, nil, DefaultConfig())
+	d.check()
+	if d.consumerSet.Cardinality() != 5 {
+		t.Fatalf("expected 5 consumers owned before the blip, got %d", d.consumerSet.Cardinality())
+	}
+
+	consumerDao.failNext = 1
+	d.check() // consumerDao.All fails; updateConsumerSet returns the error for check to log, leaving consumerSet untouched
+	if d.consumerSet.Cardinality() != 5 {
+		t.Fatalf("expected consumer set to survive a failed consumerDao.All call, got %d", d.consumerSet.Cardinality())
+	}
+
+	d.check() // blip resolved, should still be consistent
+	if d.consumerSet.Cardinality() != 5 {
+		t.Fatalf("expected 5 consumers owned after the blip cleared, got %d", d.consumerSet.Cardinality())
+	}
+}
+
+// TestResyncWorkerPanicRecoveredByStopper simulates a resync worker crashing mid-rebalance
+// (failpoint.PanicDuringDispatch) and asserts that lifecycle.Stopper recovers it and
+// reports it as a shutdown error, instead of crashing the process.
+func TestResyncWorkerPanicRecoveredByStopper(t *testing.T) {
+	failpoint.Enable(failpoint.PanicDuringDispatch)
+	defer failpoint.Disable(failpoint.PanicDuringDispatch)
+
+	instanceDao := &fakeInstanceDao{instances: []*api.ServerInstance{{Meta: api.Meta{ID: "instance-a"}}}}
+	consumerDao := newFakeConsumerDao(consumerIDs(1))
+	d := NewHashDispatcher("instance-a", instanceDao, consumerDao, fakeSourceClient{}, nil, nil, DefaultConfig())
+
+	stopper := lifecycle.NewStopper(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopper.RunWorker(ctx, "dispatch-under-test", func(ctx context.Context) {
+		d.Dispatch("consumer-0")
+	})
+	cancel()
+
+	if err := stopper.Stop(context.Background()); err == nil {
+		t.Fatal("expected Stop to report the recovered panic as an error")
+	}
+}
+
+// TestSkipUpdateConsumerSetSimulatesMissedRebalance exercises the SkipUpdateConsumerSet
+// failpoint: while enabled, check() must not change the consumer set even though
+// membership changed; once disabled, the next check() converges.
+func TestSkipUpdateConsumerSetSimulatesMissedRebalance(t *testing.T) {
+	instanceDao := &fakeInstanceDao{instances: []*api.ServerInstance{{Meta: api.Meta{ID: "instance-a"}}}}
+	consumerDao := newFakeConsumerDao(consumerIDs(3))
+	d := NewHashDispatcher("instance-a", instanceDao, consumerDao, fakeSourceClient{}, nil, nil, DefaultConfig())
+
+	failpoint.Enable(failpoint.SkipUpdateConsumerSet)
+	d.check()
+	if d.consumerSet.Cardinality() != 0 {
+		t.Fatalf("expected the missed rebalance to leave the consumer set empty, got %d", d.consumerSet.Cardinality())
+	}
+	failpoint.Disable(failpoint.SkipUpdateConsumerSet)
+
+	d.check()
+	if d.consumerSet.Cardinality() != 3 {
+		t.Fatalf("expected the next reconcile pass to converge to 3 consumers, got %d", d.consumerSet.Cardinality())
+	}
+}
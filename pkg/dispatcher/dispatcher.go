@@ -0,0 +1,29 @@
+package dispatcher
+
+import (
+	"context"
+
+	"github.com/openshift-online/maestro/pkg/api"
+)
+
+// Dispatcher decides which maestro instance owns a given consumer, so that only one
+// instance processes resource status updates for that consumer at any point in time.
+// Implementations trade off consistency and latency differently: HashDispatcher is
+// eventually consistent and favors availability, while RaftDispatcher is strongly
+// consistent and favors correctness during membership changes.
+type Dispatcher interface {
+	// Start runs the dispatcher until ctx is canceled. It is expected to be called in
+	// its own goroutine.
+	Start(ctx context.Context)
+
+	// Dispatch returns true if the given consumer is owned by the current maestro
+	// instance, i.e. this instance should process resource status updates from it.
+	Dispatch(consumerID string) bool
+
+	// OnInstanceUp notifies the dispatcher that a maestro instance has come up, or that
+	// its Weight/Draining state has changed.
+	OnInstanceUp(instance *api.ServerInstance) error
+
+	// OnInstanceDown notifies the dispatcher that a maestro instance has gone down.
+	OnInstanceDown(instanceID string) error
+}
@@ -0,0 +1,35 @@
+package dispatcher
+
+import (
+	"fmt"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/openshift-online/maestro/pkg/client/cloudevents"
+	"github.com/openshift-online/maestro/pkg/dao"
+)
+
+const (
+	// BackendHash selects HashDispatcher, the default eventually-consistent dispatcher.
+	BackendHash = "hash"
+	// BackendRaft selects RaftDispatcher, a strongly-consistent dispatcher backed by etcd.
+	BackendRaft = "raft"
+)
+
+// NewDispatcher builds the Dispatcher implementation selected by backend (one of
+// BackendHash or BackendRaft). etcdClient is only required, and only used, when backend is
+// BackendRaft; hashConfig is only used when backend is BackendHash.
+func NewDispatcher(backend, instanceID string, instanceDao dao.InstanceDao, consumerDao dao.ConsumerDao, sourceClient cloudevents.SourceClient,
+	membershipWatcher MembershipWatcher, consumerWatcher ConsumerWatcher, etcdClient *clientv3.Client, hashConfig Config) (Dispatcher, error) {
+	switch backend {
+	case "", BackendHash:
+		return NewHashDispatcher(instanceID, instanceDao, consumerDao, sourceClient, membershipWatcher, consumerWatcher, hashConfig), nil
+	case BackendRaft:
+		if etcdClient == nil {
+			return nil, fmt.Errorf("raft dispatcher backend requires an etcd client")
+		}
+		return NewRaftDispatcher(instanceID, instanceDao, consumerDao, sourceClient, etcdClient), nil
+	default:
+		return nil, fmt.Errorf("unknown dispatcher backend %q, expected %q or %q", backend, BackendHash, BackendRaft)
+	}
+}
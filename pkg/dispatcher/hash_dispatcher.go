@@ -12,6 +12,8 @@ import (
 	"github.com/openshift-online/maestro/pkg/api"
 	"github.com/openshift-online/maestro/pkg/client/cloudevents"
 	"github.com/openshift-online/maestro/pkg/dao"
+	"github.com/openshift-online/maestro/pkg/failpoint"
+	"github.com/openshift-online/maestro/pkg/lifecycle"
 	"github.com/openshift-online/maestro/pkg/logger"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/workqueue"
@@ -19,103 +21,437 @@ import (
 
 var _ Dispatcher = &HashDispatcher{}
 
+// reconcileInterval is the period of the slow, full re-scan of instances and
+// consumers that runs as a safety net in case membership/consumer events are
+// ever missed by the watchers (or no watchers are configured at all).
+const reconcileInterval = 5 * time.Minute
+
+// Config holds the tunable consistent-hashing parameters for HashDispatcher, so they can be
+// set per environment instead of being hard-coded.
+type Config struct {
+	// PartitionCount is how many partitions consumer IDs are distributed among; pick a
+	// bigger value for deployments with more consumers.
+	PartitionCount int
+	// ReplicationFactor is the number of ring replicas a weight-1 instance gets; an
+	// instance with Weight W gets ReplicationFactor*W replicas, so heavier instances
+	// absorb a proportionally larger share of consumers.
+	ReplicationFactor int
+	// Load is used by the underlying library to calculate average load; 1.25 is
+	// reasonable for most cases.
+	Load float64
+}
+
+// DefaultConfig returns HashDispatcher's historical hard-coded tuning, suitable for small
+// deployments.
+func DefaultConfig() Config {
+	return Config{
+		PartitionCount:    7,
+		ReplicationFactor: 20,
+		Load:              1.25,
+	}
+}
+
 // HashDispatcher is an implementation of Dispatcher. It uses consistent hashing to map consumers to maestro instances.
 // Only the maestro instance that is mapped to a consumer will process the resource status update from that consumer.
 // Need to trigger status resync for the consumer when an instance is up or down.
 type HashDispatcher struct {
-	instanceID   string
-	instanceDao  dao.InstanceDao
-	consumerDao  dao.ConsumerDao
-	sourceClient cloudevents.SourceClient
-	consumerSet  mapset.Set[string]
-	workQueue    workqueue.RateLimitingInterface
-	consistent   *consistent.Consistent
+	instanceID        string
+	instanceDao       dao.InstanceDao
+	consumerDao       dao.ConsumerDao
+	sourceClient      cloudevents.SourceClient
+	membershipWatcher MembershipWatcher
+	consumerWatcher   ConsumerWatcher
+	consumerSet       mapset.Set[string]
+	workQueue         workqueue.RateLimitingInterface
+	stopper           *lifecycle.Stopper
+
+	baseReplicationFactor int
+
+	mu                sync.RWMutex
+	consistent        *consistent.Consistent
+	instances         map[string]*api.ServerInstance // live, non-draining ring members, by ID
+	replicaToInstance map[string]string              // ring member key -> real instance ID
+	selfDraining      bool
 }
 
-func NewHashDispatcher(instanceID string, instanceDao dao.InstanceDao, consumerDao dao.ConsumerDao, sourceClient cloudevents.SourceClient) *HashDispatcher {
+// NewHashDispatcher creates a HashDispatcher. membershipWatcher and consumerWatcher are
+// optional (nil is allowed); when provided, instance/consumer membership changes are
+// applied incrementally as they are pushed instead of waiting on the reconciliation loop.
+// A zero-value field in config is replaced with DefaultConfig's value for it.
+func NewHashDispatcher(instanceID string, instanceDao dao.InstanceDao, consumerDao dao.ConsumerDao, sourceClient cloudevents.SourceClient,
+	membershipWatcher MembershipWatcher, consumerWatcher ConsumerWatcher, config Config) *HashDispatcher {
+	defaults := DefaultConfig()
+	if config.PartitionCount <= 0 {
+		config.PartitionCount = defaults.PartitionCount
+	}
+	if config.ReplicationFactor <= 0 {
+		config.ReplicationFactor = defaults.ReplicationFactor
+	}
+	if config.Load <= 0 {
+		config.Load = defaults.Load
+	}
+
 	return &HashDispatcher{
-		instanceID:   instanceID,
-		instanceDao:  instanceDao,
-		consumerDao:  consumerDao,
-		sourceClient: sourceClient,
-		consumerSet:  mapset.NewSet[string](),
-		workQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "hash-dispatcher"),
+		instanceID:            instanceID,
+		instanceDao:           instanceDao,
+		consumerDao:           consumerDao,
+		sourceClient:          sourceClient,
+		membershipWatcher:     membershipWatcher,
+		consumerWatcher:       consumerWatcher,
+		consumerSet:           mapset.NewSet[string](),
+		workQueue:             workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "hash-dispatcher"),
+		baseReplicationFactor: config.ReplicationFactor,
+		instances:             make(map[string]*api.ServerInstance),
+		replicaToInstance:     make(map[string]string),
 		consistent: consistent.New(nil, consistent.Config{
-			PartitionCount:    7,    // consumer IDs are distributed among partitions, select a big PartitionCount for more consumers.
-			ReplicationFactor: 20,   // the numbers for maestro instances to be replicated on consistent hash ring.
-			Load:              1.25, // Load is used to calculate average load, 1.25 is reasonable for most cases.
+			PartitionCount: config.PartitionCount,
+			// replicas are added explicitly per-instance, proportional to Weight, via
+			// addReplicas instead of the library's uniform ReplicationFactor.
+			ReplicationFactor: 1,
+			Load:              config.Load,
 			Hasher:            hasher{},
 		}),
 	}
 }
 
+// WithStopper registers the dispatcher's background goroutines with stopper, so a single
+// stopper.Stop(ctx) can drain them. Must be called before Start.
+func (d *HashDispatcher) WithStopper(stopper *lifecycle.Stopper) *HashDispatcher {
+	d.stopper = stopper
+	return d
+}
+
 // Start initializes and runs the dispatcher, updating the hashing ring and consumer set for the current instance.
 func (d *HashDispatcher) Start(ctx context.Context) {
+	if d.stopper != nil {
+		// wind down all of this dispatcher's workers as soon as the stopper quiesces,
+		// in addition to (not instead of) honoring ctx cancellation.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		d.stopper.RunWorker(ctx, "hash-dispatcher-quiesce", func(ctx context.Context) {
+			select {
+			case <-d.stopper.ShouldQuiesce():
+				cancel()
+			case <-ctx.Done():
+			}
+		})
+	}
+
+	runWorker := func(name string, fn func(ctx context.Context)) {
+		if d.stopper != nil {
+			d.stopper.RunWorker(ctx, name, fn)
+			return
+		}
+		go fn(ctx)
+	}
+
 	// start a goroutine to handle status resync requests
-	go d.startStatusResyncWorkers(ctx)
+	runWorker("hash-dispatcher-resync", d.startStatusResyncWorkers)
 
-	// start a goroutine to periodically check the instances and consumers.
-	go wait.Until(d.check, 5*time.Second, ctx.Done())
+	// start goroutines to consume pushed membership/consumer change events, if configured
+	if d.membershipWatcher != nil {
+		runWorker("hash-dispatcher-membership-watch", d.watchMembership)
+	}
+	if d.consumerWatcher != nil {
+		runWorker("hash-dispatcher-consumer-watch", d.watchConsumers)
+	}
+
+	// start a goroutine to periodically reconcile the instances and consumers, as a
+	// safety net in case a push event was ever missed.
+	runWorker("hash-dispatcher-reconcile", func(ctx context.Context) {
+		wait.Until(d.check, reconcileInterval, ctx.Done())
+	})
 
 	// wait until context is canceled
 	<-ctx.Done()
 	d.workQueue.ShutDown()
 }
 
+// watchMembership consumes instance membership events pushed by membershipWatcher and
+// applies them to the hashing ring incrementally, instead of waiting for the next
+// reconciliation pass.
+func (d *HashDispatcher) watchMembership(ctx context.Context) {
+	log := logger.NewOCMLogger(ctx)
+
+	events, err := d.membershipWatcher.Start()
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to start membership watcher: %s", err.Error()))
+		return
+	}
+	defer d.membershipWatcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			var handleErr error
+			switch event.Type {
+			case Added, Updated:
+				handleErr = d.OnInstanceUp(&api.ServerInstance{
+					Meta:     api.Meta{ID: event.InstanceID},
+					Weight:   event.Weight,
+					Draining: event.Draining,
+				})
+			case Removed:
+				handleErr = d.OnInstanceDown(event.InstanceID)
+			case Reconciled:
+				// the watcher reconnected and may have missed updates; fall back to a
+				// full reconcile instead of applying it as a single instance change.
+				d.check()
+			}
+			if handleErr != nil {
+				log.Error(fmt.Sprintf("Unable to handle instance %s event for %s: %s", event.Type, event.InstanceID, handleErr.Error()))
+			}
+		}
+	}
+}
+
+// watchConsumers consumes consumer change events pushed by consumerWatcher and applies them
+// to the consumer set incrementally, one consumer at a time, instead of re-running the full
+// consumerDao.All() rescan that updateConsumerSet does for the periodic safety net.
+func (d *HashDispatcher) watchConsumers(ctx context.Context) {
+	log := logger.NewOCMLogger(ctx)
+
+	events, err := d.consumerWatcher.Start()
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to start consumer watcher: %s", err.Error()))
+		return
+	}
+	defer d.consumerWatcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			var handleErr error
+			switch event.Type {
+			case Added, Updated:
+				handleErr = d.reconcileConsumer(ctx, event.ConsumerID)
+			case Removed:
+				// the consumer no longer exists, so there is no owner to resolve on the
+				// ring; just drop it from the current instance's set if it was there.
+				d.consumerSet.Remove(event.ConsumerID)
+				instanceConsumerCount.WithLabelValues(d.instanceID).Set(float64(d.consumerSet.Cardinality()))
+			case Reconciled:
+				// the watcher reconnected and may have missed updates; fall back to a
+				// full reconcile instead of applying it as a single consumer change.
+				d.check()
+			}
+			if handleErr != nil {
+				log.Error(fmt.Sprintf("Unable to handle consumer %s event for %s: %s", event.Type, event.ConsumerID, handleErr.Error()))
+			}
+		}
+	}
+}
+
 // Dispatch checks if the provided consumer ID is owned by the current maestro instance.
 // It returns true if the consumer is part of the current instance's consumer set;
 // otherwise, it returns false.
 func (d *HashDispatcher) Dispatch(consumerID string) bool {
+	if failpoint.Eval(failpoint.PanicDuringDispatch) {
+		panic("failpoint: panic during dispatch")
+	}
+
 	return d.consumerSet.Contains(consumerID)
 }
 
-// OnInstanceUp adds the new instance to the hashing ring and updates the consumer set for the current instance.
-func (d *HashDispatcher) OnInstanceUp(instanceID string) error {
-	members := d.consistent.GetMembers()
-	for _, member := range members {
-		if member.String() == instanceID {
-			// instance already exists, hashing ring won't be changed
-			return nil
-		}
+// OnInstanceUp adds or updates instance's replicas on the hashing ring, proportional to its
+// Weight, and refreshes the consumer set for the current instance. A Draining instance is
+// removed from the ring instead, so it stops being assigned new consumers; see
+// updateConsumerSet for how a draining current instance hands off the consumers it already
+// owns.
+func (d *HashDispatcher) OnInstanceUp(instance *api.ServerInstance) error {
+	if !d.applyInstanceUp(instance) {
+		return nil
 	}
+	return d.updateConsumerSet()
+}
 
-	// add the new instance to the hashing ring
-	d.consistent.Add(&api.ServerInstance{
-		Meta: api.Meta{
-			ID: instanceID,
-		},
-	})
+// applyInstanceUp updates the ring and instance bookkeeping for instance under d.mu, and
+// reports whether anything changed.
+func (d *HashDispatcher) applyInstanceUp(instance *api.ServerInstance) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	return d.updateConsumerSet()
+	if instance.ID == d.instanceID {
+		d.selfDraining = instance.Draining
+	}
+
+	existing, known := d.instances[instance.ID]
+	switch {
+	case known && !instance.Draining && existing.Weight == instance.Weight:
+		// already present with the same weight, hashing ring won't change
+		return false
+	case !known && instance.Draining:
+		// never ring-placed and still draining, nothing to remove
+		return false
+	}
+
+	d.removeReplicasLocked(instance.ID)
+	if instance.Draining {
+		delete(d.instances, instance.ID)
+	} else {
+		d.instances[instance.ID] = instance
+		d.addReplicasLocked(instance)
+	}
+	return true
 }
 
-// OnInstanceDown removes the instance from the hashing ring and updates the consumer set for the current instance.
+// OnInstanceDown removes every replica of instanceID from the hashing ring and refreshes
+// the consumer set for the current instance.
 func (d *HashDispatcher) OnInstanceDown(instanceID string) error {
-	members := d.consistent.GetMembers()
-	deletedMember := true
-	for _, member := range members {
-		if member.String() == instanceID {
-			// the instance is still in the hashing ring
-			deletedMember = false
-			break
+	if !d.applyInstanceDown(instanceID) {
+		return nil
+	}
+	return d.updateConsumerSet()
+}
+
+// applyInstanceDown removes instanceID's bookkeeping under d.mu and reports whether
+// anything changed.
+func (d *HashDispatcher) applyInstanceDown(instanceID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if instanceID == d.instanceID {
+		d.selfDraining = false
+	}
+	if _, known := d.instances[instanceID]; !known {
+		return false
+	}
+	d.removeReplicasLocked(instanceID)
+	delete(d.instances, instanceID)
+	return true
+}
+
+// maxInstanceWeight caps the Weight replicaCount will honor, so a misreported or buggy
+// Weight (e.g. from bad pod annotations) can't blow up ring size or memory.
+const maxInstanceWeight = 100
+
+// replicaCount returns how many virtual ring members instance should get, proportional to
+// its Weight. An unset (zero or negative) Weight is treated as 1, so instances that don't
+// report one get the historical uniform replication factor; Weight is capped at
+// maxInstanceWeight.
+func (d *HashDispatcher) replicaCount(instance *api.ServerInstance) int {
+	weight := instance.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	if weight > maxInstanceWeight {
+		weight = maxInstanceWeight
+	}
+	return d.baseReplicationFactor * int(weight)
+}
+
+// addReplicasLocked adds instance's virtual ring members, recording their mapping back to
+// instance.ID in replicaToInstance so LocateKey results can be resolved to a real instance.
+// Callers must hold d.mu for writing.
+func (d *HashDispatcher) addReplicasLocked(instance *api.ServerInstance) {
+	for i := 0; i < d.replicaCount(instance); i++ {
+		key := fmt.Sprintf("%s#%d", instance.ID, i)
+		d.replicaToInstance[key] = instance.ID
+		d.consistent.Add(replica(key))
+	}
+}
+
+// removeReplicasLocked removes every virtual ring member previously added for instanceID.
+// Callers must hold d.mu for writing.
+func (d *HashDispatcher) removeReplicasLocked(instanceID string) {
+	for key, owner := range d.replicaToInstance {
+		if owner == instanceID {
+			d.consistent.Remove(key)
+			delete(d.replicaToInstance, key)
 		}
 	}
+}
+
+// ownerOf resolves the virtual ring member LocateKey returns for id back to the real
+// instance ID that owns it, or "" if the ring has no members yet.
+func (d *HashDispatcher) ownerOf(id string) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.replicaToInstance) == 0 {
+		return ""
+	}
+	member := d.consistent.LocateKey([]byte(id))
+	if member == nil {
+		return ""
+	}
+	return d.replicaToInstance[member.String()]
+}
 
-	// if the instance is already deleted, the hash ring won't be changed
-	if deletedMember {
+// reconcileConsumer resolves consumerID's owner on the hashing ring and updates the current
+// instance's consumer set and ownership claim accordingly. It is the single-consumer unit of
+// work shared by updateConsumerSet's full rescan and watchConsumers' incremental, per-event
+// updates, so an instance being pushed an event for one consumer doesn't have to pay for a
+// full consumerDao.All() scan to apply it.
+func (d *HashDispatcher) reconcileConsumer(ctx context.Context, consumerID string) error {
+	d.mu.RLock()
+	ringReady := len(d.replicaToInstance) > 0
+	selfDraining := d.selfDraining
+	d.mu.RUnlock()
+
+	// nothing to resolve against if the hashing ring is not ready
+	if !ringReady {
 		return nil
 	}
 
-	// remove the instance from the hashing ring
-	d.consistent.Remove(instanceID)
+	owner := d.ownerOf(consumerID)
+	if owner == d.instanceID {
+		if !d.consumerSet.Contains(consumerID) {
+			// new consumer added to the current instance, need to resync resource status updates for this consumer
+			d.consumerSet.Add(consumerID)
+			d.workQueue.Add(consumerID)
+		}
+		if err := d.consumerDao.ClaimOwner(ctx, consumerID, d.instanceID); err != nil {
+			return fmt.Errorf("unable to claim consumer %s: %s", consumerID, err.Error())
+		}
+	} else if d.consumerSet.Contains(consumerID) {
+		if selfDraining {
+			// while draining, keep serving a consumer this instance still owns
+			// until the new owner confirms it has taken over by claiming it,
+			// instead of giving it up the moment the ring stops resolving here
+			// (which would risk both instances processing it at once).
+			claimedOwner, err := d.consumerDao.Owner(ctx, consumerID)
+			if err != nil {
+				return fmt.Errorf("unable to read owner of consumer %s: %s", consumerID, err.Error())
+			}
+			if claimedOwner != owner {
+				return nil
+			}
+		}
+		d.consumerSet.Remove(consumerID)
+	}
 
-	return d.updateConsumerSet()
+	instanceConsumerCount.WithLabelValues(d.instanceID).Set(float64(d.consumerSet.Cardinality()))
+	return nil
 }
 
-// updateConsumerSet updates the consumer set for the current instance based on the hashing ring.
+// updateConsumerSet re-scans every consumer and updates the consumer set for the current
+// instance based on the hashing ring. This is the slow path used by the periodic safety-net
+// reconcile (check) and a Reconciled watcher event; watchConsumers applies ordinary
+// Added/Updated/Removed events via reconcileConsumer instead, without a full rescan.
 func (d *HashDispatcher) updateConsumerSet() error {
+	if failpoint.Eval(failpoint.SkipUpdateConsumerSet) {
+		// simulate a missed rebalance: pretend the consumer set is already up to date
+		return nil
+	}
+
+	d.mu.RLock()
+	ringReady := len(d.replicaToInstance) > 0
+	numInstances := len(d.instances)
+	d.mu.RUnlock()
+
 	// return if the hashing ring is not ready
-	if d.consistent == nil || len(d.consistent.GetMembers()) == 0 {
+	if !ringReady {
 		return nil
 	}
 
@@ -128,29 +464,17 @@ func (d *HashDispatcher) updateConsumerSet() error {
 		return fmt.Errorf("unable to list consumers: %s", err.Error())
 	}
 
-	toAddConsumers, toRemoveConsumers := []string{}, []string{}
 	for _, consumer := range consumers {
-		instanceID := d.consistent.LocateKey([]byte(consumer.ID)).String()
-		if instanceID == d.instanceID {
-			if !d.consumerSet.Contains(consumer.ID) {
-				// new consumer added to the current instance, need to resync resource status updates for this consumer
-				// log.V(4).Infof("Adding new consumer %s to consumer set", consumer.ID)
-				toAddConsumers = append(toAddConsumers, consumer.ID)
-				d.workQueue.Add(consumer.ID)
-			}
-		} else {
-			// remove the consumer from the set if it is not in the current instance
-			if d.consumerSet.Contains(consumer.ID) {
-				// log.V(4).Infof("Removing consumer %s from consumer set", consumer.ID)
-				toRemoveConsumers = append(toRemoveConsumers, consumer.ID)
-			}
+		if err := d.reconcileConsumer(ctx, consumer.ID); err != nil {
+			log.Error(fmt.Sprintf("Unable to reconcile consumer %s: %s", consumer.ID, err.Error()))
 		}
 	}
-
-	_ = d.consumerSet.Append(toAddConsumers...)
-	d.consumerSet.RemoveAll(toRemoveConsumers...)
 	log.V(4).Infof("Consumers set for current instance: %s", d.consumerSet.String())
 
+	if numInstances > 0 {
+		instanceConsumerCountAvg.WithLabelValues(d.instanceID).Set(float64(len(consumers)) / float64(numInstances))
+	}
+
 	return nil
 }
 
@@ -169,7 +493,9 @@ func (d *HashDispatcher) startStatusResyncWorkers(ctx context.Context) {
 	wg.Wait()
 }
 
-// check checks the instances & consumers and updates the hashing ring and consumer set for the current instance.
+// check is the slow reconciliation safety net: it re-scans all instances & consumers and
+// updates the hashing ring and consumer set for the current instance, catching up on any
+// push events that the watchers may have missed.
 func (d *HashDispatcher) check() {
 	ctx := context.TODO()
 	log := logger.NewOCMLogger(ctx)
@@ -180,20 +506,24 @@ func (d *HashDispatcher) check() {
 		return
 	}
 
-	// ensure the hashing ring members are up-to-date
-	members := d.consistent.GetMembers()
-	for _, member := range members {
-		isMemberActive := false
-		for _, instance := range instances {
-			if member.String() == instance.ID {
-				isMemberActive = true
-				break
-			}
-		}
-		if !isMemberActive {
-			d.consistent.Remove(member.String())
+	active := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		active[instance.ID] = true
+		d.applyInstanceUp(instance)
+	}
+
+	d.mu.RLock()
+	stale := make([]string, 0)
+	for id := range d.instances {
+		if !active[id] {
+			stale = append(stale, id)
 		}
 	}
+	d.mu.RUnlock()
+
+	for _, id := range stale {
+		d.applyInstanceDown(id)
+	}
 
 	if err := d.updateConsumerSet(); err != nil {
 		log.Error(fmt.Sprintf("Unable to update consumer set: %s", err.Error()))
@@ -224,6 +554,11 @@ func (d *HashDispatcher) processNextResync(ctx context.Context) bool {
 		return true
 	}
 
+	if failpoint.Eval(failpoint.DelayResync) {
+		// widen the race window with a concurrent check/updateConsumerSet pass
+		time.Sleep(time.Second)
+	}
+
 	log := logger.NewOCMLogger(ctx)
 	log.V(4).Infof("processing status resync request for consumer %s", consumerIDStr)
 	if err := d.sourceClient.Resync(ctx, []string{consumerIDStr}); err != nil {
@@ -242,3 +577,12 @@ type hasher struct{}
 func (h hasher) Sum64(data []byte) uint64 {
 	return xxhash.Sum64(data)
 }
+
+// replica is a single virtual replica of a maestro instance placed on the consistent hash
+// ring; instances with Weight > 1 get proportionally more (see addReplicasLocked).
+type replica string
+
+// String implements consistent.Member (github.com/buraksezer/consistent).
+func (r replica) String() string {
+	return string(r)
+}
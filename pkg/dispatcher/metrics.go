@@ -0,0 +1,24 @@
+package dispatcher
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// instanceConsumerCount is the number of consumers currently owned by this maestro
+	// instance, so operators can compare instances against each other to validate that
+	// weighted placement is keeping load balanced.
+	instanceConsumerCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "maestro_dispatcher_instance_consumer_count",
+		Help: "Number of consumers currently owned by this maestro instance.",
+	}, []string{"instance_id"})
+
+	// instanceConsumerCountAvg is the average number of consumers per live maestro
+	// instance, for comparison against instanceConsumerCount.
+	instanceConsumerCountAvg = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "maestro_dispatcher_instance_consumer_count_average",
+		Help: "Average number of consumers owned per live maestro instance.",
+	}, []string{"instance_id"})
+)
+
+func init() {
+	prometheus.MustRegister(instanceConsumerCount, instanceConsumerCountAvg)
+}
@@ -0,0 +1,140 @@
+package dispatcher
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/lib/pq"
+)
+
+const (
+	instancesChannel = "server_instances_events"
+	consumersChannel = "consumers_events"
+
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+)
+
+// notifyPayload is the JSON body emitted by the `server_instances`/`consumers`
+// triggers on INSERT/UPDATE/DELETE, e.g.
+//
+//	{"type": "Added", "id": "instance-1", "weight": 2, "draining": false}
+//
+// weight and draining are only populated by the `server_instances` trigger; the
+// `consumers` trigger omits them.
+type notifyPayload struct {
+	Type     EventType `json:"type"`
+	ID       string    `json:"id"`
+	Weight   int32     `json:"weight"`
+	Draining bool      `json:"draining"`
+}
+
+// PostgresMembershipWatcher watches the `server_instances` table for changes
+// using Postgres LISTEN/NOTIFY. A database trigger on `server_instances` must
+// publish a notifyPayload on the instancesChannel for every row change.
+type PostgresMembershipWatcher struct {
+	listener *pq.Listener
+}
+
+// NewPostgresMembershipWatcher creates a membership watcher that listens on
+// instancesChannel using connStr to establish its own dedicated connection,
+// as required by database/sql/pq's LISTEN/NOTIFY support.
+func NewPostgresMembershipWatcher(connStr string) *PostgresMembershipWatcher {
+	listener := pq.NewListener(connStr, minReconnectInterval, maxReconnectInterval, reportListenerProblem)
+	return &PostgresMembershipWatcher{listener: listener}
+}
+
+// Start implements MembershipWatcher.
+func (w *PostgresMembershipWatcher) Start() (<-chan InstanceEvent, error) {
+	if err := w.listener.Listen(instancesChannel); err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %s", instancesChannel, err.Error())
+	}
+
+	events := make(chan InstanceEvent)
+	go func() {
+		defer close(events)
+		for n := range w.listener.Notify {
+			if n == nil {
+				// a reconnection happened and we may have missed notifications while
+				// disconnected; emit a Reconciled event so the caller does a full
+				// reconcile instead of only relying on the next safety-net pass.
+				events <- InstanceEvent{Type: Reconciled}
+				continue
+			}
+			var payload notifyPayload
+			if err := json.Unmarshal([]byte(n.Extra), &payload); err != nil {
+				glog.Errorf("unable to unmarshal instance notification payload %q: %s", n.Extra, err.Error())
+				continue
+			}
+			events <- InstanceEvent{Type: payload.Type, InstanceID: payload.ID, Weight: payload.Weight, Draining: payload.Draining}
+		}
+	}()
+
+	return events, nil
+}
+
+// Stop implements MembershipWatcher.
+func (w *PostgresMembershipWatcher) Stop() error {
+	return w.listener.Close()
+}
+
+// PostgresConsumerWatcher watches the `consumers` table for changes using
+// Postgres LISTEN/NOTIFY. A database trigger on `consumers` must publish a
+// notifyPayload on the consumersChannel for every row change.
+type PostgresConsumerWatcher struct {
+	listener *pq.Listener
+}
+
+// NewPostgresConsumerWatcher creates a consumer watcher that listens on
+// consumersChannel using connStr to establish its own dedicated connection,
+// as required by database/sql/pq's LISTEN/NOTIFY support.
+func NewPostgresConsumerWatcher(connStr string) *PostgresConsumerWatcher {
+	listener := pq.NewListener(connStr, minReconnectInterval, maxReconnectInterval, reportListenerProblem)
+	return &PostgresConsumerWatcher{listener: listener}
+}
+
+// Start implements ConsumerWatcher.
+func (w *PostgresConsumerWatcher) Start() (<-chan ConsumerEvent, error) {
+	if err := w.listener.Listen(consumersChannel); err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %s", consumersChannel, err.Error())
+	}
+
+	events := make(chan ConsumerEvent)
+	go func() {
+		defer close(events)
+		for n := range w.listener.Notify {
+			if n == nil {
+				// a reconnection happened and we may have missed notifications while
+				// disconnected; emit a Reconciled event so the caller does a full
+				// reconcile instead of only relying on the next safety-net pass.
+				events <- ConsumerEvent{Type: Reconciled}
+				continue
+			}
+			var payload notifyPayload
+			if err := json.Unmarshal([]byte(n.Extra), &payload); err != nil {
+				glog.Errorf("unable to unmarshal consumer notification payload %q: %s", n.Extra, err.Error())
+				continue
+			}
+			events <- ConsumerEvent{Type: payload.Type, ConsumerID: payload.ID}
+		}
+	}()
+
+	return events, nil
+}
+
+// Stop implements ConsumerWatcher.
+func (w *PostgresConsumerWatcher) Stop() error {
+	return w.listener.Close()
+}
+
+// reportListenerProblem logs unexpected pq.Listener events; events with a nil error or with
+// driver.ErrBadConn are not logged.
+func reportListenerProblem(event pq.ListenerEventType, err error) {
+	if err == nil || err == driver.ErrBadConn {
+		return
+	}
+	glog.Errorf("postgres listener problem: %s", err.Error())
+}
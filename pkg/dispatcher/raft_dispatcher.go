@@ -0,0 +1,185 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift-online/maestro/pkg/api"
+	"github.com/openshift-online/maestro/pkg/client/cloudevents"
+	"github.com/openshift-online/maestro/pkg/dao"
+	"github.com/openshift-online/maestro/pkg/logger"
+)
+
+var _ Dispatcher = &RaftDispatcher{}
+
+const (
+	// leaseTTL is how long a consumer->instance ownership key is valid for without a
+	// keep-alive. Ownership handoff on instance-down waits for the previous lease to
+	// expire (or be explicitly revoked) before a new owner is confirmed.
+	leaseTTL = 15 * time.Second
+
+	// claimInterval is how often the dispatcher scans for consumers that have no
+	// current owner and attempts to claim them.
+	claimInterval = 2 * time.Second
+
+	consumerKeyPrefix = "/maestro/dispatch/consumers/"
+	electionPrefix    = "/maestro/dispatch/election/"
+)
+
+// RaftDispatcher is an implementation of Dispatcher backed by an etcd cluster. Consumer
+// ownership is a lease-scoped etcd key acquired via a create-if-absent transaction, so
+// Dispatch is strongly consistent.
+type RaftDispatcher struct {
+	instanceID   string
+	instanceDao  dao.InstanceDao
+	consumerDao  dao.ConsumerDao
+	sourceClient cloudevents.SourceClient
+	client       *clientv3.Client
+	leaseID      clientv3.LeaseID
+
+	mu    sync.RWMutex
+	owned map[string]bool
+}
+
+// NewRaftDispatcher creates a RaftDispatcher that stores consumer ownership leases in the
+// given etcd client. client is expected to point at an embedded or external etcd cluster.
+func NewRaftDispatcher(instanceID string, instanceDao dao.InstanceDao, consumerDao dao.ConsumerDao, sourceClient cloudevents.SourceClient, client *clientv3.Client) *RaftDispatcher {
+	return &RaftDispatcher{
+		instanceID:   instanceID,
+		instanceDao:  instanceDao,
+		consumerDao:  consumerDao,
+		sourceClient: sourceClient,
+		client:       client,
+		owned:        make(map[string]bool),
+	}
+}
+
+// Start grants the dispatcher's lease, keeps it alive for as long as ctx is live, and
+// periodically attempts to claim ownership of unowned or abandoned consumers.
+func (d *RaftDispatcher) Start(ctx context.Context) {
+	log := logger.NewOCMLogger(ctx)
+
+	lease, err := d.client.Grant(ctx, int64(leaseTTL.Seconds()))
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to grant etcd lease: %s", err.Error()))
+		return
+	}
+	d.leaseID = lease.ID
+
+	keepAlive, err := d.client.KeepAlive(ctx, d.leaseID)
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to keep etcd lease alive: %s", err.Error()))
+		return
+	}
+	go func() {
+		for range keepAlive {
+			// drain keep-alive responses; a closed channel means the lease expired
+			// or ctx was canceled, either of which release this instance's claims.
+		}
+	}()
+
+	go wait.Until(func() { d.claimConsumers(ctx) }, claimInterval, ctx.Done())
+
+	<-ctx.Done()
+}
+
+// Dispatch returns true if the consumer's ownership key in etcd is held by this instance.
+func (d *RaftDispatcher) Dispatch(consumerID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.owned[consumerID]
+}
+
+// OnInstanceUp is a no-op for RaftDispatcher: ownership is driven entirely by etcd lease
+// state, there is no hashing ring to add members to. Weight and Draining only affect
+// placement in ring-based dispatchers.
+func (d *RaftDispatcher) OnInstanceUp(instance *api.ServerInstance) error {
+	return nil
+}
+
+// OnInstanceDown is a no-op for RaftDispatcher: the departed instance's leases will expire
+// on their own TTL (or can be explicitly revoked by an operator), at which point
+// claimConsumers picks up the now-unowned consumers.
+func (d *RaftDispatcher) OnInstanceDown(instanceID string) error {
+	return nil
+}
+
+// claimConsumers lists all consumers and attempts to acquire ownership of any that are not
+// currently owned by another instance, via an etcd create-if-absent transaction scoped to
+// this instance's lease.
+func (d *RaftDispatcher) claimConsumers(ctx context.Context) {
+	log := logger.NewOCMLogger(ctx)
+
+	consumers, err := d.consumerDao.All(ctx)
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to list consumers: %s", err.Error()))
+		return
+	}
+
+	owned := make(map[string]bool, len(consumers))
+	for _, consumer := range consumers {
+		key := consumerKeyPrefix + consumer.ID
+		txn := d.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, d.instanceID, clientv3.WithLease(d.leaseID)))
+		resp, err := txn.Commit()
+		if err != nil {
+			log.Error(fmt.Sprintf("Unable to claim consumer %s: %s", consumer.ID, err.Error()))
+			continue
+		}
+
+		if resp.Succeeded {
+			owned[consumer.ID] = true
+			continue
+		}
+
+		// someone already owns this consumer; check whether it's us (e.g. after a
+		// reconnect) so Dispatch keeps returning true for consumers we still hold.
+		getResp, err := d.client.Get(ctx, key)
+		if err != nil {
+			log.Error(fmt.Sprintf("Unable to read owner of consumer %s: %s", consumer.ID, err.Error()))
+			continue
+		}
+		if len(getResp.Kvs) > 0 && string(getResp.Kvs[0].Value) == d.instanceID {
+			owned[consumer.ID] = true
+		}
+	}
+
+	d.mu.Lock()
+	d.owned = owned
+	d.mu.Unlock()
+}
+
+// RunAsSingleton blocks until it is elected leader of electionName, then invokes run with a
+// context canceled on leadership loss, for controllers that must only run on one instance.
+func RunAsSingleton(ctx context.Context, client *clientv3.Client, electionName string, run func(context.Context)) error {
+	session, err := concurrency.NewSession(client)
+	if err != nil {
+		return fmt.Errorf("unable to create etcd session: %s", err.Error())
+	}
+	defer session.Close()
+
+	election := concurrency.NewElection(session, electionPrefix+electionName)
+	if err := election.Campaign(ctx, ""); err != nil {
+		return fmt.Errorf("unable to campaign for leadership of %s: %s", electionName, err.Error())
+	}
+	defer election.Resign(context.Background())
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// if the session expires (e.g. this instance is partitioned), leadership is
+		// lost and run must stop.
+		<-session.Done()
+		cancel()
+	}()
+
+	run(runCtx)
+	return nil
+}
@@ -0,0 +1,53 @@
+package dispatcher
+
+// EventType identifies the kind of change a watcher observed.
+type EventType string
+
+const (
+	Added   EventType = "Added"
+	Removed EventType = "Removed"
+	Updated EventType = "Updated"
+
+	// Reconciled signals that the watcher reconnected and may have missed changes while
+	// disconnected; it carries no InstanceID/ConsumerID and callers should treat it as a
+	// request to fully recompute from the source of truth, rather than apply it as a
+	// single membership change.
+	Reconciled EventType = "Reconciled"
+)
+
+// InstanceEvent describes a change to a maestro server instance's membership, including
+// the Weight/Draining state a ring-based dispatcher needs to place it.
+type InstanceEvent struct {
+	Type       EventType
+	InstanceID string
+	Weight     int32
+	Draining   bool
+}
+
+// ConsumerEvent describes a change to a consumer.
+type ConsumerEvent struct {
+	Type       EventType
+	ConsumerID string
+}
+
+// MembershipWatcher watches the set of maestro server instances and emits
+// an event whenever an instance is added, removed or updated, so that
+// dispatchers can react without polling.
+type MembershipWatcher interface {
+	// Start begins watching for instance membership changes and returns a
+	// channel of events. The channel is closed once the watcher is stopped.
+	Start() (<-chan InstanceEvent, error)
+	// Stop stops the watcher and releases any underlying resources.
+	Stop() error
+}
+
+// ConsumerWatcher watches the set of consumers and emits an event whenever a
+// consumer is added, removed or updated, so that dispatchers can react
+// without polling.
+type ConsumerWatcher interface {
+	// Start begins watching for consumer changes and returns a channel of
+	// events. The channel is closed once the watcher is stopped.
+	Start() (<-chan ConsumerEvent, error)
+	// Stop stops the watcher and releases any underlying resources.
+	Stop() error
+}
@@ -0,0 +1,27 @@
+// Package failpoint is a small in-tree failpoint framework, in the spirit of
+// github.com/pingcap/failpoint, for injecting faults into hard-to-reproduce rebalancing
+// paths (HashDispatcher's check/updateConsumerSet/processNextResync loops and the pulse
+// server's heartbeat loop) so that chaos tests can reproduce them on demand instead of
+// waiting for them to happen in the wild.
+//
+// Outside of builds tagged "failpoints", Eval always returns false and Enable/Disable are
+// no-ops, so instrumented call sites compile down to their normal behavior with no runtime
+// cost in production binaries.
+package failpoint
+
+// Names of the failpoints instrumented across maestro. Keeping them as constants avoids
+// typos when toggling a failpoint from a test or from the HTTP endpoint in Handler.
+const (
+	// SkipUpdateConsumerSet makes HashDispatcher.updateConsumerSet return immediately
+	// without reconciling the consumer set, simulating a missed rebalance.
+	SkipUpdateConsumerSet = "skipUpdateConsumerSet"
+	// DelayResync makes HashDispatcher.processNextResync pause before resyncing,
+	// widening the race window with a concurrent check/updateConsumerSet pass.
+	DelayResync = "delayResync"
+	// DropInstanceHeartbeat makes the pulse server's heartbeat loop skip a beat,
+	// simulating a DB blip or a missed heartbeat that delays instance-down detection.
+	DropInstanceHeartbeat = "dropInstanceHeartbeat"
+	// PanicDuringDispatch makes HashDispatcher.Dispatch panic, simulating a resync
+	// worker crashing mid-rebalance.
+	PanicDuringDispatch = "panicDuringDispatch"
+)
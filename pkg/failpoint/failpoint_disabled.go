@@ -0,0 +1,15 @@
+//go:build !failpoints
+
+package failpoint
+
+// Enable is a no-op outside of failpoints builds.
+func Enable(name string) {}
+
+// Disable is a no-op outside of failpoints builds.
+func Disable(name string) {}
+
+// Eval always returns false outside of failpoints builds.
+func Eval(name string) bool { return false }
+
+// Status always returns an empty set outside of failpoints builds.
+func Status() map[string]bool { return nil }
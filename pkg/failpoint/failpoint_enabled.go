@@ -0,0 +1,43 @@
+//go:build failpoints
+
+package failpoint
+
+import "sync"
+
+var (
+	mu      sync.RWMutex
+	enabled = map[string]bool{}
+)
+
+// Enable turns the named failpoint on.
+func Enable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled[name] = true
+}
+
+// Disable turns the named failpoint off.
+func Disable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(enabled, name)
+}
+
+// Eval reports whether the named failpoint is currently enabled. Call sites gate the
+// behavior they want to inject on this, e.g. `if failpoint.Eval(failpoint.DelayResync) { ... }`.
+func Eval(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled[name]
+}
+
+// Status returns a snapshot of every currently enabled failpoint, for Handler.
+func Status() map[string]bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	status := make(map[string]bool, len(enabled))
+	for name, on := range enabled {
+		status[name] = on
+	}
+	return status
+}
@@ -0,0 +1,36 @@
+package failpoint
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Handler serves a tiny HTTP API for toggling failpoints at runtime:
+//
+//	GET  /debug/failpoints             -> currently enabled failpoints
+//	POST /debug/failpoints?name=<name>&enable=true|false
+//
+// It is intended to be mounted on the metrics server behind the --enable-failpoints flag,
+// so that chaos tests can drive it over HTTP instead of needing to run in-process.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, "%v\n", Status())
+		case http.MethodPost:
+			name := r.URL.Query().Get("name")
+			if name == "" {
+				http.Error(w, `missing required "name" query parameter`, http.StatusBadRequest)
+				return
+			}
+			if r.URL.Query().Get("enable") == "false" {
+				Disable(name)
+			} else {
+				Enable(name)
+			}
+			fmt.Fprintf(w, "%v\n", Status())
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package lifecycle centralizes startup and graceful shutdown of maestro's long-running
+// servers and background workers.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// DefaultDrainDeadline bounds how long Stop waits for registered workers and closers to
+// finish before giving up, so that shutdown is always bounded in time.
+const DefaultDrainDeadline = 30 * time.Second
+
+// Closer is a server or resource with a synchronous, bounded shutdown step, e.g. an HTTP
+// server wrapper's Stop method.
+type Closer interface {
+	Stop() error
+}
+
+// Stopper tracks the servers and background workers registered with it (à la cockroachdb's
+// stop.Stopper) and performs ordered, bounded, error-aggregating shutdown on Stop.
+type Stopper struct {
+	drainDeadline time.Duration
+
+	quiescer chan struct{} // closed when Stop begins; see ShouldQuiesce
+	stopped  chan struct{} // closed once Stop has finished draining
+	wg       sync.WaitGroup
+	numTasks int32 // atomic
+
+	mu      sync.Mutex
+	closers []namedCloser
+	errs    []error
+}
+
+type namedCloser struct {
+	name   string
+	closer Closer
+}
+
+// NewStopper creates a Stopper whose Stop will wait at most drainDeadline for registered
+// workers and closers to finish before giving up and returning. A drainDeadline <= 0 uses
+// DefaultDrainDeadline.
+func NewStopper(drainDeadline time.Duration) *Stopper {
+	if drainDeadline <= 0 {
+		drainDeadline = DefaultDrainDeadline
+	}
+	return &Stopper{
+		drainDeadline: drainDeadline,
+		quiescer:      make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// RunWorker runs fn in its own goroutine, tracking it so that Stop waits for it to return
+// (up to the drain deadline) before completing. fn should select on ShouldQuiesce and
+// return promptly once it is closed. A panic in fn is recovered and reported as part of
+// Stop's aggregated error instead of crashing the process.
+func (s *Stopper) RunWorker(ctx context.Context, name string, fn func(ctx context.Context)) {
+	atomic.AddInt32(&s.numTasks, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer atomic.AddInt32(&s.numTasks, -1)
+		defer func() {
+			if r := recover(); r != nil {
+				s.recordError(fmt.Errorf("worker %q panicked: %v", name, r))
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+// ShouldQuiesce returns a channel that is closed once Stop is called, signaling registered
+// workers to wind down. Workers should select on it the way they would select on a
+// context's Done channel.
+func (s *Stopper) ShouldQuiesce() <-chan struct{} {
+	return s.quiescer
+}
+
+// AddCloser registers a server or resource to be stopped when Stop runs. Closers are
+// stopped one at a time, in the reverse of their registration order, after ShouldQuiesce
+// is closed and before Stop waits on RunWorker tasks; this lets e.g. a listener be closed
+// before Stop waits for the workers reading from it to return. Time spent stopping
+// closers counts against the drain deadline.
+func (s *Stopper) AddCloser(name string, c Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closers = append(s.closers, namedCloser{name: name, closer: c})
+}
+
+// NumTasks returns the number of RunWorker tasks currently registered and not yet
+// returned. It is intended to be surfaced as shutdown progress, e.g. by the health server.
+func (s *Stopper) NumTasks() int {
+	return int(atomic.LoadInt32(&s.numTasks))
+}
+
+// IsStopped returns a channel that is closed once Stop has finished draining (successfully
+// or not).
+func (s *Stopper) IsStopped() <-chan struct{} {
+	return s.stopped
+}
+
+// Stop signals all registered workers to quiesce via ShouldQuiesce, stops registered
+// closers in LIFO order, and then waits for workers to return, all within the configured
+// drain deadline. It always returns once the drain deadline elapses or ctx is canceled, even
+// if closers or workers are still running, and aggregates any closer or worker errors it
+// encountered.
+func (s *Stopper) Stop(ctx context.Context) error {
+	defer close(s.stopped)
+	close(s.quiescer)
+
+	s.mu.Lock()
+	closers := s.closers
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			nc := closers[i]
+			if err := nc.closer.Stop(); err != nil {
+				s.recordError(fmt.Errorf("unable to stop %s: %s", nc.name, err.Error()))
+			}
+		}
+		s.wg.Wait()
+		close(done)
+	}()
+
+	deadline := time.NewTimer(s.drainDeadline)
+	defer deadline.Stop()
+
+	select {
+	case <-done:
+	case <-deadline.C:
+		s.recordError(fmt.Errorf("drain deadline of %s exceeded with %d worker(s) still running", s.drainDeadline, s.NumTasks()))
+	case <-ctx.Done():
+		s.recordError(fmt.Errorf("context canceled while draining: %s", ctx.Err()))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d error(s) during shutdown: %w", len(s.errs), errors.Join(s.errs...))
+}
+
+func (s *Stopper) recordError(err error) {
+	glog.Errorf("%s", err.Error())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}